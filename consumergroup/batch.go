@@ -0,0 +1,162 @@
+package consumergroup
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// batchKey identifies the (topic, partition) a batch belongs to. Batches
+// never cross partition boundaries, so commit semantics stay exactly what
+// they'd be for the individual messages in the batch.
+type batchKey struct {
+	topic     string
+	partition int32
+}
+
+// defaultBatchPeriod is the idle-flush period batcher falls back to when
+// Config.Batching is left at its zero value, so an unconfigured Batches()
+// call still delivers messages instead of accumulating them forever.
+const defaultBatchPeriod = 1 * time.Second
+
+// BatchingConfig controls Batches(): a partition's batch is flushed once it
+// reaches Count messages or ByteSize bytes, or once Period has elapsed
+// since a message was last added to it, whichever happens first. The zero
+// value disables all three thresholds; Batches() then falls back to
+// defaultBatchPeriod instead of never flushing.
+type BatchingConfig struct {
+	Count    int
+	ByteSize int
+	Period   time.Duration
+}
+
+// batcher groups messages read off a ConsumerGroup's messages channel into
+// per-partition batches, flushing each batch once it hits the configured
+// count, byte size, or idle period.
+type batcher struct {
+	cg     *ConsumerGroup
+	out    chan []*sarama.ConsumerMessage
+	policy BatchingConfig
+
+	mu      sync.Mutex
+	batches map[batchKey][]*sarama.ConsumerMessage
+	bytes   map[batchKey]int
+	timers  map[batchKey]*time.Timer
+}
+
+// Batches returns a channel of per-partition message batches, grouped
+// according to Config.Batching. A batch is flushed when its message count
+// or byte size reaches the configured threshold, or when its partition has
+// gone idle for Config.Batching.Period, whichever happens first. If
+// Config.Batching is left unconfigured (its zero value), batches fall back
+// to an idle-flush period of defaultBatchPeriod so the channel isn't
+// silently starved.
+func (cg *ConsumerGroup) Batches() <-chan []*sarama.ConsumerMessage {
+	cg.batchOnce.Do(func() {
+		cg.batches = make(chan []*sarama.ConsumerMessage, cg.config.ChannelBufferSize)
+
+		policy := cg.config.Batching
+		if policy.Count <= 0 && policy.ByteSize <= 0 && policy.Period <= 0 {
+			cg.logWarn("Batches: Config.Batching is unconfigured, falling back to a default idle-flush period", "period", defaultBatchPeriod)
+			policy.Period = defaultBatchPeriod
+		}
+
+		b := &batcher{
+			cg:      cg,
+			out:     cg.batches,
+			policy:  policy,
+			batches: make(map[batchKey][]*sarama.ConsumerMessage),
+			bytes:   make(map[batchKey]int),
+			timers:  make(map[batchKey]*time.Timer),
+		}
+		go b.run()
+	})
+
+	return cg.batches
+}
+
+// CommitBatch marks the highest offset per (topic, partition) in batch as
+// processed, through offsetManager.MarkAsProcessed, in a single pass, and
+// returns the first error MarkAsProcessed reports.
+// Because Batches() never mixes partitions within a batch, this is
+// equivalent to calling CommitUpto with the batch's last message.
+func (cg *ConsumerGroup) CommitBatch(batch []*sarama.ConsumerMessage) error {
+	highest := make(map[batchKey]int64)
+	for _, message := range batch {
+		key := batchKey{topic: message.Topic, partition: message.Partition}
+		if offset, ok := highest[key]; !ok || message.Offset > offset {
+			highest[key] = message.Offset
+		}
+	}
+
+	var firstErr error
+	for key, offset := range highest {
+		if err := cg.offsetManager.MarkAsProcessed(key.topic, key.partition, offset); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (b *batcher) run() {
+	policy := b.policy
+
+	for message := range b.cg.messages {
+		key := batchKey{topic: message.Topic, partition: message.Partition}
+
+		b.mu.Lock()
+		b.batches[key] = append(b.batches[key], message)
+		b.bytes[key] += len(message.Key) + len(message.Value)
+
+		switch {
+		case policy.Count > 0 && len(b.batches[key]) >= policy.Count:
+			b.flushLocked(key)
+		case policy.ByteSize > 0 && b.bytes[key] >= policy.ByteSize:
+			b.flushLocked(key)
+		case policy.Period > 0:
+			b.resetIdleTimerLocked(key, policy.Period)
+		}
+		b.mu.Unlock()
+	}
+
+	b.mu.Lock()
+	for key := range b.batches {
+		b.flushLocked(key)
+	}
+	b.mu.Unlock()
+
+	close(b.out)
+}
+
+// flushLocked sends the accumulated batch for key, if any, and must be
+// called with b.mu held.
+func (b *batcher) flushLocked(key batchKey) {
+	batch := b.batches[key]
+	if len(batch) == 0 {
+		return
+	}
+
+	delete(b.batches, key)
+	delete(b.bytes, key)
+	if timer, ok := b.timers[key]; ok {
+		timer.Stop()
+		delete(b.timers, key)
+	}
+
+	b.out <- batch
+}
+
+// resetIdleTimerLocked (re)starts the idle-flush timer for key and must be
+// called with b.mu held.
+func (b *batcher) resetIdleTimerLocked(key batchKey, period time.Duration) {
+	if timer, ok := b.timers[key]; ok {
+		timer.Stop()
+	}
+
+	b.timers[key] = time.AfterFunc(period, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.flushLocked(key)
+	})
+}
@@ -0,0 +1,92 @@
+package consumergroup
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/funkygao/kazoo-go"
+)
+
+// Config configures a ConsumerGroup. The embedded *sarama.Config tunes the
+// underlying Sarama consumer and client; NewConfig returns one with sane
+// defaults for both it and the fields below.
+type Config struct {
+	*sarama.Config
+
+	// Zookeeper configures the connection kazoo.NewKazoo makes to discover
+	// brokers, topics and partition ownership.
+	Zookeeper *kazoo.Config
+
+	// ChannelBufferSize sizes the Messages(), Errors(), Batches() and
+	// Notifications() channels.
+	ChannelBufferSize int
+
+	// NoDup deduplicates re-delivered messages (after a rebalance or
+	// restart) using an in-memory cache, at the cost of some memory.
+	NoDup bool
+
+	Offsets struct {
+		// Initial is where to start consuming a partition that has no
+		// committed offset yet: sarama.OffsetOldest or sarama.OffsetNewest.
+		Initial int64
+
+		// ProcessingTimeout bounds how long FinalizePartition waits for a
+		// partition's last consumed message to be marked processed before
+		// committing whatever has been confirmed and giving up.
+		ProcessingTimeout time.Duration
+
+		// CommitInterval is how often queued offsets are flushed to the
+		// backing store.
+		CommitInterval time.Duration
+
+		// ResetOffsets, if true, resets this consumer group's committed
+		// offsets in Zookeeper before joining.
+		ResetOffsets bool
+
+		// Storage selects where offsets are committed: OffsetStorageZookeeper
+		// (the default), OffsetStorageKafka, or OffsetStorageDual.
+		Storage string
+	}
+
+	// Logger receives structured log output from ConsumerGroup and its
+	// offset managers. Defaults to NewStdLogAdapter() if left nil.
+	Logger Logger
+
+	// Batching configures Batches(). Its zero value falls back to a
+	// default idle-flush period rather than never flushing.
+	Batching BatchingConfig
+}
+
+// NewConfig returns a Config with sane defaults.
+func NewConfig() *Config {
+	config := &Config{
+		Config:    sarama.NewConfig(),
+		Zookeeper: kazoo.NewConfig(),
+	}
+
+	config.ChannelBufferSize = 256
+
+	config.Offsets.Initial = sarama.OffsetOldest
+	config.Offsets.ProcessingTimeout = 60 * time.Second
+	config.Offsets.CommitInterval = 10 * time.Second
+	config.Offsets.Storage = OffsetStorageZookeeper
+
+	return config
+}
+
+// Validate checks the config for inconsistencies, on top of what the
+// embedded sarama.Config already validates.
+func (c *Config) Validate() error {
+	if c.Zookeeper == nil {
+		return errors.New("kafka-cg: no Zookeeper configuration provided")
+	}
+
+	switch c.Offsets.Storage {
+	case OffsetStorageZookeeper, OffsetStorageKafka, OffsetStorageDual:
+	default:
+		return sarama.ConfigurationError("Offsets.Storage must be one of OffsetStorageZookeeper, OffsetStorageKafka, OffsetStorageDual")
+	}
+
+	return c.Config.Validate()
+}
@@ -1,8 +1,6 @@
 package consumergroup
 
 import (
-	"log"
-	"os"
 	"sync"
 	"time"
 
@@ -14,11 +12,12 @@ import (
 // The ConsumerGroup type holds all the information for a consumer that is part
 // of a consumer group. Call JoinConsumerGroup to start a consumer.
 type ConsumerGroup struct {
-	Logger *log.Logger
+	Logger Logger
 
 	config *Config
 
 	consumer sarama.Consumer
+	client   sarama.Client
 
 	kazoo     *kazoo.Kazoo
 	group     *kazoo.Consumergroup
@@ -34,19 +33,66 @@ type ConsumerGroup struct {
 
 	offsetManager OffsetManager
 	cacher        *freecache.Cache
+
+	hwmMu          sync.RWMutex
+	highWaterMarks map[string]map[int32]int64
+
+	lastConsumedMu      sync.RWMutex
+	lastConsumedOffsets map[string]map[int32]int64
+
+	batchOnce sync.Once
+	batches   chan []*sarama.ConsumerMessage
+
+	notificationsOnce sync.Once
+	notifications     chan *Notification
+	claims            claimsTracker
+
+	pauses pauseTracker
 }
 
 // Connects to a consumer group, using Zookeeper for auto-discovery
 func JoinConsumerGroup(name string, topics []string, zookeeper []string,
 	config *Config) (cg *ConsumerGroup, err error) {
-	if name == "" {
-		return nil, sarama.ConfigurationError("Empty consumergroup name")
-	}
 	if len(topics) == 0 {
 		return nil, sarama.ConfigurationError("No topics provided")
 	}
+
+	var brokers []string
+	cg, brokers, err = newConsumerGroup(name, zookeeper, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Register itself with zookeeper: consumers/{group}/ids/{instanceId}
+	// This will lead to consumer group rebalance
+	if err := cg.instance.Register(topics); err != nil {
+		return nil, err
+	} else {
+		cg.logInfo("consumer instance registered", "topics", topics)
+	}
+
+	if err := cg.setupOffsetManager(brokers); err != nil {
+		_ = cg.consumer.Close()
+		_ = cg.kazoo.Close()
+		return nil, err
+	}
+
+	go cg.consumeTopics(topics)
+
+	return
+}
+
+// newConsumerGroup performs the Zookeeper/Kafka bootstrapping shared by
+// JoinConsumerGroup and JoinConsumerGroupRegex: validating config, connecting
+// to Zookeeper and the Kafka brokers it advertises, and creating the
+// consumer group znode if it doesn't exist yet. Callers are responsible for
+// registering the instance with its topic set and for setupOffsetManager.
+func newConsumerGroup(name string, zookeeper []string, config *Config) (cg *ConsumerGroup, brokers []string, err error) {
+	if name == "" {
+		return nil, nil, sarama.ConfigurationError("Empty consumergroup name")
+	}
 	if len(zookeeper) == 0 {
-		return nil, EmptyZkAddrs
+		return nil, nil, EmptyZkAddrs
 	}
 
 	if config == nil {
@@ -54,93 +100,112 @@ func JoinConsumerGroup(name string, topics []string, zookeeper []string,
 	}
 	config.ClientID = name
 	if err = config.Validate(); err != nil {
-		return
+		return nil, nil, err
+	}
+
+	groupLogger := config.Logger
+	if groupLogger == nil {
+		groupLogger = NewStdLogAdapter()
 	}
 
 	var kz *kazoo.Kazoo
 	if kz, err = kazoo.NewKazoo(zookeeper, config.Zookeeper); err != nil {
-		return
+		return nil, nil, err
 	}
 
-	var brokers []string
 	brokers, err = kz.BrokerList()
 	if err != nil {
 		kz.Close()
-		return
+		return nil, nil, err
 	}
 
 	group := kz.Consumergroup(name)
 
 	if config.Offsets.ResetOffsets {
-		err = group.ResetOffsets()
-		if err != nil {
-			log.Printf("FAILED to reset offsets of consumergroup: %s!\n", err)
+		if err = group.ResetOffsets(); err != nil {
+			groupLogger.Error("reset consumer group offsets", "err", err)
 			kz.Close()
-			return
+			return nil, nil, err
 		}
 	}
 
 	var consumer sarama.Consumer
 	if consumer, err = sarama.NewConsumer(brokers, config.Config); err != nil {
 		kz.Close()
-		return
+		return nil, nil, err
 	}
 
-	instance := group.NewInstance()
 	cg = &ConsumerGroup{
-		Logger: log.New(os.Stdout, "[KafkaConsumerGroup] ", log.Ldate|log.Ltime),
+		Logger: groupLogger,
 
 		config:   config,
 		consumer: consumer,
 
 		kazoo:    kz,
 		group:    group,
-		instance: instance,
+		instance: group.NewInstance(),
 
 		messages: make(chan *sarama.ConsumerMessage, config.ChannelBufferSize),
 		errors:   make(chan *sarama.ConsumerError, config.ChannelBufferSize),
 		stopper:  make(chan struct{}),
+
+		highWaterMarks:      make(map[string]map[int32]int64),
+		lastConsumedOffsets: make(map[string]map[int32]int64),
 	}
 	if config.NoDup {
 		cg.cacher = freecache.NewCache(1 << 20) // TODO
 	}
 
 	// Register consumer group in zookeeper
-	exists, err1 := cg.group.Exists()
-	if err1 != nil {
+	exists, err := cg.group.Exists()
+	if err != nil {
 		_ = consumer.Close()
 		_ = kz.Close()
-		return nil, err1
+		return nil, nil, err
 	}
 	if !exists {
-		cg.Logger.Printf("[%s/%s] consumer group in zk creating...\n", cg.group.Name, cg.shortID())
+		cg.logInfo("consumer group in zk creating")
 
 		if err := cg.group.Create(); err != nil {
 			_ = consumer.Close()
 			_ = kz.Close()
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
-	// Register itself with zookeeper: consumers/{group}/ids/{instanceId}
-	// This will lead to consumer group rebalance
-	if err := cg.instance.Register(topics); err != nil {
-		return nil, err
-	} else {
-		cg.Logger.Printf("[%s/%s] consumer instance registered in zk for %+v\n", cg.group.Name,
-			cg.shortID(), topics)
-	}
+	return cg, brokers, nil
+}
+
+// setupOffsetManager picks the offset manager backend according to
+// cg.config.Offsets.Storage, connecting a Kafka client when needed.
+func (cg *ConsumerGroup) setupOffsetManager(brokers []string) error {
+	offsetConfig := OffsetManagerConfig{CommitInterval: cg.config.Offsets.CommitInterval}
+	switch cg.config.Offsets.Storage {
+	case OffsetStorageKafka, OffsetStorageDual:
+		client, err := sarama.NewClient(brokers, cg.config.Config)
+		if err != nil {
+			return err
+		}
+		cg.client = client
 
-	offsetConfig := OffsetManagerConfig{CommitInterval: config.Offsets.CommitInterval}
-	cg.offsetManager = NewZookeeperOffsetManager(cg, &offsetConfig)
+		if cg.config.Offsets.Storage == OffsetStorageDual {
+			cg.offsetManager, err = NewDualOffsetManager(cg, &offsetConfig)
+		} else {
+			cg.offsetManager, err = NewKafkaOffsetManager(cg, &offsetConfig)
+		}
+		if err != nil {
+			return err
+		}
 
-	go cg.consumeTopics(topics)
+	default:
+		cg.offsetManager = NewZookeeperOffsetManager(cg, &offsetConfig)
+	}
 
-	return
+	return nil
 }
 
 // SetLogger overrides the default logger
-func (cg *ConsumerGroup) SetLogger(l *log.Logger) {
+func (cg *ConsumerGroup) SetLogger(l Logger) {
 	cg.Logger = l
 }
 
@@ -163,7 +228,7 @@ func (cg *ConsumerGroup) Close() error {
 	cg.singleShutdown.Do(func() {
 		defer cg.kazoo.Close()
 
-		cg.Logger.Printf("[%s/%s] closing...", cg.group.Name, cg.shortID())
+		cg.logInfo("closing")
 
 		shutdownError = nil
 
@@ -171,23 +236,29 @@ func (cg *ConsumerGroup) Close() error {
 		cg.wg.Wait()
 
 		if err := cg.offsetManager.Close(); err != nil {
-			cg.Logger.Printf("[%s/%s] closing offset manager: %s\n", cg.group.Name, cg.shortID(), err)
+			cg.logError("closing offset manager", "err", err)
 		}
 
 		if shutdownError = cg.instance.Deregister(); shutdownError != nil {
-			cg.Logger.Printf("[%s/%s] de-register consumer instance: %s\n", cg.group.Name, cg.shortID(), shutdownError)
+			cg.logError("de-register consumer instance", "err", shutdownError)
 		} else {
-			cg.Logger.Printf("[%s/%s] de-registered consumer instance\n", cg.group.Name, cg.shortID())
+			cg.logInfo("de-registered consumer instance")
 		}
 
 		if shutdownError = cg.consumer.Close(); shutdownError != nil {
-			cg.Logger.Printf("[%s/%s] closing Sarama consumer: %v\n", cg.group.Name, cg.shortID(), shutdownError)
+			cg.logError("closing Sarama consumer", "err", shutdownError)
+		}
+
+		if cg.client != nil {
+			if err := cg.client.Close(); err != nil {
+				cg.logError("closing Sarama client", "err", err)
+			}
 		}
 
 		close(cg.messages)
 		close(cg.errors)
 
-		cg.Logger.Printf("[%s/%s] closed\n", cg.group.Name, cg.shortID())
+		cg.logInfo("closed")
 
 		cg.instance = nil
 	})
@@ -223,7 +294,7 @@ func (cg *ConsumerGroup) consumeTopics(topics []string) {
 		consumers, consumerChanges, err := cg.group.WatchInstances()
 		if err != nil {
 			// FIXME write to err chan?
-			cg.Logger.Printf("[%s/%s] watch consumer instances: %s\n", cg.group.Name, cg.shortID(), err)
+			cg.logError("watch consumer instances", "err", err)
 			return
 		}
 
@@ -253,26 +324,23 @@ func (cg *ConsumerGroup) consumeTopics(topics []string) {
 			// iptables -D  OUTPUT -p tcp -m tcp --dport 2181 -j      # rm rule
 			registered, err := cg.instance.Registered()
 			if err != nil {
-				cg.Logger.Printf("[%s/%s] %s", cg.group.Name, cg.shortID(), err)
+				cg.logError("check instance registration", "err", err)
 			} else if !registered { // this sub instances was killed
 				err = cg.instance.Register(topics)
 				if err != nil {
-					cg.Logger.Printf("[%s/%s] register consumer instance for %+v: %s\n",
-						cg.group.Name, cg.shortID(), topics, err)
+					cg.logError("register consumer instance", "topics", topics, "err", err)
 				} else {
-					cg.Logger.Printf("[%s/%s] re-registered consumer instance for %+v\n",
-						cg.group.Name, cg.shortID(), topics)
+					cg.logInfo("re-registered consumer instance", "topics", topics)
+					cg.notify(&Notification{Type: InstanceReregistered, Current: cg.claims.snapshot()})
 				}
 			}
 
-			cg.Logger.Printf("[%s/%s] rebalance due to %+v consumer list change\n",
-				cg.group.Name, cg.shortID(), topics)
+			cg.logInfo("rebalance: consumer list changed", "topics", topics)
 			close(topicConsumerStopper) // notify all topic consumers stop
 			cg.wg.Wait()                // wait for all topic consumers finish
 
 		case <-topicChanges:
-			cg.Logger.Printf("[%s/%s] rebalance due to topic %+v change\n",
-				cg.group.Name, cg.shortID(), topics)
+			cg.logInfo("rebalance: topic partitions changed", "topics", topics)
 			close(topicConsumerStopper) // notify all topic consumers stop
 			cg.wg.Wait()                // wait for all topic consumers finish
 		}
@@ -283,7 +351,7 @@ func (cg *ConsumerGroup) consumeTopics(topics []string) {
 func (cg *ConsumerGroup) watchTopicChange(topic string, stopper <-chan struct{}, topicChanges chan<- struct{}) {
 	_, topicPartitionChanges, err := cg.kazoo.Topic(topic).WatchPartitions()
 	if err != nil {
-		cg.Logger.Printf("[%s/%s] topic %s: %s\n", cg.group.Name, cg.shortID(), topic, err)
+		cg.logError("watch topic partitions", "topic", topic, "err", err)
 		// FIXME err chan?
 		return
 	}
@@ -310,11 +378,11 @@ func (cg *ConsumerGroup) consumeTopic(topic string, messages chan<- *sarama.Cons
 	default:
 	}
 
-	cg.Logger.Printf("[%s/%s] try consuming topic: %s\n", cg.group.Name, cg.shortID(), topic)
+	cg.logInfo("try consuming topic", "topic", topic)
 
 	partitions, err := cg.kazoo.Topic(topic).Partitions()
 	if err != nil {
-		cg.Logger.Printf("[%s/%s] get topic %s partitions: %s\n", cg.group.Name, cg.shortID(), topic, err)
+		cg.logError("get topic partitions", "topic", topic, "err", err)
 		cg.errors <- &sarama.ConsumerError{
 			Topic:     topic,
 			Partition: -1,
@@ -325,7 +393,7 @@ func (cg *ConsumerGroup) consumeTopic(topic string, messages chan<- *sarama.Cons
 
 	partitionLeaders, err := retrievePartitionLeaders(partitions)
 	if err != nil {
-		cg.Logger.Printf("[%s/%s] get leader broker of topic %s partitions: %s\n", cg.group.Name, cg.shortID(), topic, err)
+		cg.logError("get topic partition leaders", "topic", topic, "err", err)
 		cg.errors <- &sarama.ConsumerError{
 			Topic:     topic,
 			Partition: -1,
@@ -337,8 +405,19 @@ func (cg *ConsumerGroup) consumeTopic(topic string, messages chan<- *sarama.Cons
 	dividedPartitions := dividePartitionsBetweenConsumers(cg.consumers, partitionLeaders)
 	myPartitions := dividedPartitions[cg.instance.ID]
 
-	cg.Logger.Printf("[%s/%s] topic %s claiming %d of %d partitions\n", cg.group.Name, cg.shortID(),
-		topic, len(myPartitions), len(partitionLeaders))
+	cg.logInfo("claiming partitions", "topic", topic, "claimed", len(myPartitions), "total", len(partitionLeaders))
+
+	myPartitionIDs := make([]int32, len(myPartitions))
+	for i, p := range myPartitions {
+		myPartitionIDs[i] = p.ID
+	}
+	claimed, released := cg.claims.update(topic, myPartitionIDs)
+	cg.notify(&Notification{
+		Type:     Rebalance,
+		Claimed:  map[string][]int32{topic: claimed},
+		Released: map[string][]int32{topic: released},
+		Current:  cg.claims.snapshot(),
+	})
 
 	if len(myPartitions) == 0 {
 		consumers := make([]string, 0, len(cg.consumers))
@@ -350,8 +429,7 @@ func (cg *ConsumerGroup) consumeTopic(topic string, messages chan<- *sarama.Cons
 			partitions = append(partitions, p.id)
 		}
 
-		cg.Logger.Printf("[%s/%s] topic %s will standby, {C:%+v, P:%+v}\n",
-			cg.group.Name, cg.shortID(), topic, consumers, partitions)
+		cg.logInfo("standby, no partitions claimed", "topic", topic, "consumers", consumers, "partitions", partitions)
 	}
 
 	// Consume all the assigned partitions
@@ -362,7 +440,7 @@ func (cg *ConsumerGroup) consumeTopic(topic string, messages chan<- *sarama.Cons
 	}
 
 	wg.Wait()
-	cg.Logger.Printf("[%s/%s] stopped consuming topic: %s\n", cg.group.Name, cg.shortID(), topic)
+	cg.logInfo("stopped consuming topic", "topic", topic)
 }
 
 func (cg *ConsumerGroup) consumePartition(topic string, partition int32, messages chan<- *sarama.ConsumerMessage,
@@ -378,36 +456,38 @@ func (cg *ConsumerGroup) consumePartition(topic string, partition int32, message
 	maxRetries := int(cg.config.Offsets.ProcessingTimeout/time.Second) + 3
 	for tries := 0; tries < maxRetries; tries++ {
 		if err := cg.instance.ClaimPartition(topic, partition); err == nil {
-			cg.Logger.Printf("[%s/%s] %s/%d claimed owner\n", cg.group.Name, cg.shortID(), topic, partition)
+			cg.logInfo("claimed owner", "topic", topic, "partition", partition)
 			break
 		} else if err == kazoo.ErrPartitionClaimedByOther && tries+1 < maxRetries {
 			time.Sleep(1 * time.Second)
 		} else {
 			// FIXME err chan?
-			cg.Logger.Printf("[%s/%s] claim %s/%d: %s\n", cg.group.Name, cg.shortID(), topic, partition, err)
+			cg.logError("claim partition", "topic", topic, "partition", partition, "err", err)
 			return
 		}
 	}
 	defer func() {
-		cg.Logger.Printf("[%s/%s] %s/%d de-claiming owner\n", cg.group.Name, cg.shortID(), topic, partition)
+		cg.logInfo("de-claiming owner", "topic", topic, "partition", partition)
 		cg.instance.ReleasePartition(topic, partition)
 	}()
 
+	paused := cg.pauses.register(topic, partition)
+	defer cg.pauses.unregister(topic, partition)
+
 	nextOffset, err := cg.offsetManager.InitializePartition(topic, partition)
 	if err != nil {
-		cg.Logger.Printf("[%s/%s] %s/%d determine initial offset: %s\n", cg.group.Name, cg.shortID(),
-			topic, partition, err)
+		cg.logError("determine initial offset", "topic", topic, "partition", partition, "err", err)
 		return
 	}
 
 	if nextOffset >= 0 {
-		cg.Logger.Printf("[%s/%s] %s/%d start offset: %d\n", cg.group.Name, cg.shortID(), topic, partition, nextOffset)
+		cg.logInfo("start offset", "topic", topic, "partition", partition, "offset", nextOffset)
 	} else {
 		nextOffset = cg.config.Offsets.Initial
 		if nextOffset == sarama.OffsetOldest {
-			cg.Logger.Printf("[%s/%s] %s/%d start offset: oldest\n", cg.group.Name, cg.shortID(), topic, partition)
+			cg.logInfo("start offset: oldest", "topic", topic, "partition", partition)
 		} else if nextOffset == sarama.OffsetNewest {
-			cg.Logger.Printf("[%s/%s] %s/%d start offset: newest\n", cg.group.Name, cg.shortID(), topic, partition)
+			cg.logInfo("start offset: newest", "topic", topic, "partition", partition)
 		}
 	}
 
@@ -417,14 +497,12 @@ func (cg *ConsumerGroup) consumePartition(topic string, partition int32, message
 		// if the configuration specified offsetOldest, then switch to the oldest available offset, else
 		// switch to the newest available offset.
 		if cg.config.Offsets.Initial == sarama.OffsetOldest {
-			cg.Logger.Printf("[%s/%s] %s/%d O:%d %s, reset to oldest\n",
-				cg.group.Name, cg.shortID(), topic, partition, nextOffset, err)
+			cg.logWarn("offset out of range, reset to oldest", "topic", topic, "partition", partition, "offset", nextOffset, "err", err)
 
 			nextOffset = sarama.OffsetOldest
 		} else {
 			// even when user specifies initial offset, it is reset to newest
-			cg.Logger.Printf("[%s/%s] %s/%d O:%d %s, reset to newest\n",
-				cg.group.Name, cg.shortID(), topic, partition, nextOffset, err)
+			cg.logWarn("offset out of range, reset to newest", "topic", topic, "partition", partition, "offset", nextOffset, "err", err)
 
 			nextOffset = sarama.OffsetNewest
 		}
@@ -434,13 +512,17 @@ func (cg *ConsumerGroup) consumePartition(topic string, partition int32, message
 	}
 	if err != nil {
 		// FIXME err chan?
-		cg.Logger.Printf("[%s/%s] %s/%d: %s", cg.group.Name, cg.shortID(), topic, partition, err)
+		cg.logError("consume partition", "topic", topic, "partition", partition, "err", err)
 		return
 	}
 	defer consumer.Close()
 
 	err = nil
 	var lastOffset int64 = -1 // aka unknown
+
+	pauseTicker := time.NewTicker(pausePollInterval)
+	defer pauseTicker.Stop()
+
 partitionConsumerLoop:
 	for {
 		select {
@@ -459,6 +541,25 @@ partitionConsumerLoop:
 			}
 
 		case message := <-consumer.Messages():
+			for paused.Load() {
+				// Paused: hold this message rather than forwarding it or
+				// advancing offsets, but keep draining errors and watching
+				// stopper so Close() and error reporting aren't starved.
+				select {
+				case <-stopper:
+					break partitionConsumerLoop
+
+				case err := <-consumer.Errors():
+					select {
+					case errors <- err:
+					case <-stopper:
+						break partitionConsumerLoop
+					}
+
+				case <-pauseTicker.C:
+				}
+			}
+
 			for {
 				select {
 				case <-stopper:
@@ -468,6 +569,8 @@ partitionConsumerLoop:
 					if message != nil {
 						lastOffset = message.Offset
 						cg.offsetManager.MarkAsConsumed(topic, partition, lastOffset)
+						cg.setHighWaterMark(topic, partition, consumer.HighWaterMarkOffset())
+						cg.setLastConsumedOffset(topic, partition, lastOffset)
 					}
 					continue partitionConsumerLoop
 				}
@@ -475,8 +578,15 @@ partitionConsumerLoop:
 		}
 	}
 
-	cg.Logger.Printf("[%s/%s] %s/%d stopping at offset: %d\n", cg.group.Name, cg.shortID(), topic, partition, lastOffset)
+	cg.logInfo("stopping partition consumer", "topic", topic, "partition", partition, "offset", lastOffset)
+	cg.claims.release(topic, partition)
+	cg.notify(&Notification{
+		Type:     PartitionsReleased,
+		Released: map[string][]int32{topic: {partition}},
+		Current:  cg.claims.snapshot(),
+	})
 	if err := cg.offsetManager.FinalizePartition(topic, partition, lastOffset, cg.config.Offsets.ProcessingTimeout); err != nil {
-		cg.Logger.Printf("[%s/%s] %s/%d: %s", cg.group.Name, cg.shortID(), topic, partition, err)
+		cg.logError("consume partition", "topic", topic, "partition", partition, "err", err)
 	}
+	cg.clearPartitionMetrics(topic, partition)
 }
@@ -0,0 +1,206 @@
+package consumergroup
+
+import (
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// topicDiscoveryInterval controls how often JoinConsumerGroupRegex re-lists
+// topics from Zookeeper to pick up topics that started or stopped matching
+// the subscription pattern.
+const topicDiscoveryInterval = 30 * time.Second
+
+// JoinConsumerGroupRegex connects to a consumer group the same way
+// JoinConsumerGroup does, except the topics to consume aren't fixed up
+// front: any topic whose name matches pattern is joined, and the match set
+// is periodically refreshed so that topics created or deleted while the
+// group is running are picked up without a restart.
+func JoinConsumerGroupRegex(name string, pattern *regexp.Regexp, zookeeper []string,
+	config *Config) (cg *ConsumerGroup, err error) {
+	if pattern == nil {
+		return nil, sarama.ConfigurationError("No topic pattern provided")
+	}
+
+	var brokers []string
+	cg, brokers, err = newConsumerGroup(name, zookeeper, config)
+	if err != nil {
+		return nil, err
+	}
+
+	topics, err := cg.matchingTopics(pattern)
+	if err != nil {
+		_ = cg.consumer.Close()
+		_ = cg.kazoo.Close()
+		return nil, err
+	}
+
+	if err := cg.instance.Register(topics); err != nil {
+		return nil, err
+	} else {
+		cg.logInfo("consumer instance registered", "topics", topics, "pattern", pattern)
+	}
+
+	if err := cg.setupOffsetManager(brokers); err != nil {
+		_ = cg.consumer.Close()
+		_ = cg.kazoo.Close()
+		return nil, err
+	}
+
+	go cg.consumeTopicsRegex(pattern, topics)
+
+	return
+}
+
+// matchingTopics lists the topics currently known to Zookeeper and returns
+// the sorted subset whose name matches pattern.
+func (cg *ConsumerGroup) matchingTopics(pattern *regexp.Regexp) ([]string, error) {
+	topics, err := cg.kazoo.Topics()
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]string, 0, len(topics))
+	for _, topic := range topics {
+		if pattern.MatchString(topic.Name) {
+			matched = append(matched, topic.Name)
+		}
+	}
+	sort.Strings(matched)
+
+	return matched, nil
+}
+
+// consumeTopicsRegex mirrors consumeTopics, except the topic set driving
+// each rebalance cycle comes from watchTopicsRegex's periodic discovery
+// instead of a fixed list, and cg.instance is re-registered whenever that
+// set changes so ZK reflects what this instance is actually claiming. Like
+// consumeTopics, each already-matched topic also gets its own
+// watchTopicChange so a partition count change on a topic this group is
+// already consuming triggers a rebalance without waiting on the next
+// topicDiscoveryInterval tick.
+func (cg *ConsumerGroup) consumeTopicsRegex(pattern *regexp.Regexp, topics []string) {
+	for {
+		select {
+		case <-cg.stopper:
+			return
+		default:
+		}
+
+		consumers, consumerChanges, err := cg.group.WatchInstances()
+		if err != nil {
+			cg.logError("watch consumer instances", "err", err)
+			return
+		}
+
+		cg.consumers = consumers
+
+		topicConsumerStopper := make(chan struct{})
+		topicChanges := make(chan struct{})
+
+		for _, topic := range topics {
+			cg.wg.Add(1)
+			go cg.watchTopicChange(topic, topicConsumerStopper, topicChanges)
+			go cg.consumeTopic(topic, cg.messages, cg.errors, topicConsumerStopper)
+		}
+		go cg.watchTopicsRegex(pattern, topics, topicConsumerStopper, topicChanges)
+
+		select {
+		case <-cg.stopper:
+			close(topicConsumerStopper) // notify all topic consumers stop
+			// cg.Close will call cg.wg.Wait()
+			return
+
+		case <-consumerChanges:
+			registered, err := cg.instance.Registered()
+			if err != nil {
+				cg.logError("check instance registration", "err", err)
+			} else if !registered { // this sub instance was killed
+				if err := cg.instance.Register(topics); err != nil {
+					cg.logError("register consumer instance", "topics", topics, "err", err)
+				} else {
+					cg.logInfo("re-registered consumer instance", "topics", topics)
+					cg.notify(&Notification{Type: InstanceReregistered, Current: cg.claims.snapshot()})
+				}
+			}
+
+			cg.logInfo("rebalance: consumer list changed", "topics", topics)
+			close(topicConsumerStopper) // notify all topic consumers stop
+			cg.wg.Wait()                // wait for all topic consumers finish
+
+		case <-topicChanges:
+			// topicChanges closes both on a periodic pattern re-scan that
+			// found a different topic set, and on a watchTopicChange firing
+			// for a partition count change on an already-matched topic.
+			// Only the former needs re-registering with Zookeeper.
+			newTopics, err := cg.matchingTopics(pattern)
+			if err != nil {
+				cg.logError("list topics matching pattern", "pattern", pattern, "err", err)
+				newTopics = topics
+			}
+
+			close(topicConsumerStopper) // notify all topic consumers stop
+			cg.wg.Wait()                // wait for all topic consumers finish
+
+			if sameTopics(topics, newTopics) {
+				cg.logInfo("rebalance: topic partitions changed", "topics", topics)
+			} else {
+				cg.logInfo("rebalance: topic set changed", "pattern", pattern, "old_topics", topics, "new_topics", newTopics)
+
+				if err := cg.instance.Register(newTopics); err != nil {
+					cg.logError("register consumer instance", "topics", newTopics, "err", err)
+				} else {
+					cg.logInfo("consumer instance re-registered", "topics", newTopics)
+					cg.notify(&Notification{Type: SubscriptionChanged, Current: cg.claims.snapshot()})
+				}
+
+				topics = newTopics
+			}
+		}
+	}
+}
+
+// watchTopicsRegex polls the topic set matching pattern and closes
+// topicChanges once it differs from the set this rebalance cycle started
+// with, triggering the same rebalance path consumeTopicsRegex takes for a
+// partition-count change.
+func (cg *ConsumerGroup) watchTopicsRegex(pattern *regexp.Regexp, topics []string, stopper <-chan struct{}, topicChanges chan<- struct{}) {
+	ticker := time.NewTicker(topicDiscoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cg.stopper:
+			return
+
+		case <-stopper:
+			return
+
+		case <-ticker.C:
+			latest, err := cg.matchingTopics(pattern)
+			if err != nil {
+				cg.logError("list topics matching pattern", "pattern", pattern, "err", err)
+				continue
+			}
+
+			if !sameTopics(topics, latest) {
+				close(topicChanges)
+				return
+			}
+		}
+	}
+}
+
+func sameTopics(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,15 @@
+package consumergroup
+
+import "errors"
+
+// Errors returned by ConsumerGroup itself, as opposed to the per-partition
+// offset-tracking errors declared in offset_manager.go.
+var (
+	// EmptyZkAddrs is returned by JoinConsumerGroup/JoinConsumerGroupRegex
+	// when called with no Zookeeper addresses.
+	EmptyZkAddrs = errors.New("kafka-cg: no Zookeeper addresses provided")
+
+	// AlreadyClosing is returned by a second call to Close() while the
+	// first is still in progress, or after Close() has already completed.
+	AlreadyClosing = errors.New("kafka-cg: consumer group is already closing")
+)
@@ -0,0 +1,113 @@
+package consumergroup
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// Logger is the structured logging interface ConsumerGroup and its offset
+// managers log through. keysAndValues are alternating key/value pairs, e.g.
+// Info("commit offset", "topic", topic, "partition", partition, "offset", offset).
+type Logger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// StdLogAdapter adapts the stdlib *log.Logger to the Logger interface by
+// rendering keysAndValues as "key=value" pairs after msg. This is what
+// ConsumerGroup used before Logger existed, and remains the default.
+type StdLogAdapter struct {
+	*log.Logger
+}
+
+// NewStdLogAdapter returns the default logger: stdlib log.Logger writing to
+// os.Stdout, matching ConsumerGroup's historical behavior.
+func NewStdLogAdapter() *StdLogAdapter {
+	return &StdLogAdapter{log.New(os.Stdout, "[KafkaConsumerGroup] ", log.Ldate|log.Ltime)}
+}
+
+func (a *StdLogAdapter) Debug(msg string, keysAndValues ...interface{}) {
+	a.log("DEBUG", msg, keysAndValues)
+}
+func (a *StdLogAdapter) Info(msg string, keysAndValues ...interface{}) {
+	a.log("INFO", msg, keysAndValues)
+}
+func (a *StdLogAdapter) Warn(msg string, keysAndValues ...interface{}) {
+	a.log("WARN", msg, keysAndValues)
+}
+func (a *StdLogAdapter) Error(msg string, keysAndValues ...interface{}) {
+	a.log("ERROR", msg, keysAndValues)
+}
+
+func (a *StdLogAdapter) log(level, msg string, keysAndValues []interface{}) {
+	a.Logger.Printf("%s %s%s\n", level, msg, formatKeysAndValues(keysAndValues))
+}
+
+func formatKeysAndValues(keysAndValues []interface{}) string {
+	if len(keysAndValues) == 0 {
+		return ""
+	}
+
+	out := ""
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		out += fmt.Sprintf(" %v=%v", keysAndValues[i], keysAndValues[i+1])
+	}
+	return out
+}
+
+// ZapSugaredLogger is the subset of *zap.SugaredLogger's API ZapAdapter
+// needs, so this package doesn't have to import zap directly.
+type ZapSugaredLogger interface {
+	Debugw(msg string, keysAndValues ...interface{})
+	Infow(msg string, keysAndValues ...interface{})
+	Warnw(msg string, keysAndValues ...interface{})
+	Errorw(msg string, keysAndValues ...interface{})
+}
+
+// ZapAdapter adapts a *zap.SugaredLogger to the Logger interface.
+type ZapAdapter struct {
+	Sugared ZapSugaredLogger
+}
+
+// NewZapAdapter wraps a *zap.SugaredLogger (or anything satisfying
+// ZapSugaredLogger) as a Logger.
+func NewZapAdapter(sugared ZapSugaredLogger) *ZapAdapter {
+	return &ZapAdapter{Sugared: sugared}
+}
+
+func (a *ZapAdapter) Debug(msg string, keysAndValues ...interface{}) {
+	a.Sugared.Debugw(msg, keysAndValues...)
+}
+func (a *ZapAdapter) Info(msg string, keysAndValues ...interface{}) {
+	a.Sugared.Infow(msg, keysAndValues...)
+}
+func (a *ZapAdapter) Warn(msg string, keysAndValues ...interface{}) {
+	a.Sugared.Warnw(msg, keysAndValues...)
+}
+func (a *ZapAdapter) Error(msg string, keysAndValues ...interface{}) {
+	a.Sugared.Errorw(msg, keysAndValues...)
+}
+
+func (cg *ConsumerGroup) logDebug(msg string, keysAndValues ...interface{}) {
+	cg.Logger.Debug(msg, cg.withInstance(keysAndValues)...)
+}
+
+func (cg *ConsumerGroup) logInfo(msg string, keysAndValues ...interface{}) {
+	cg.Logger.Info(msg, cg.withInstance(keysAndValues)...)
+}
+
+func (cg *ConsumerGroup) logWarn(msg string, keysAndValues ...interface{}) {
+	cg.Logger.Warn(msg, cg.withInstance(keysAndValues)...)
+}
+
+func (cg *ConsumerGroup) logError(msg string, keysAndValues ...interface{}) {
+	cg.Logger.Error(msg, cg.withInstance(keysAndValues)...)
+}
+
+func (cg *ConsumerGroup) withInstance(keysAndValues []interface{}) []interface{} {
+	prefix := []interface{}{"group", cg.group.Name, "instance", cg.shortID()}
+	return append(prefix, keysAndValues...)
+}
@@ -0,0 +1,79 @@
+package consumergroup
+
+// HighWaterMarks returns the most recently observed high-water mark, as
+// reported by sarama's PartitionConsumer, for every partition this instance
+// currently has claimed and has delivered at least one message from. Safe
+// to call concurrently with consumePartition.
+func (cg *ConsumerGroup) HighWaterMarks() map[string]map[int32]int64 {
+	cg.hwmMu.RLock()
+	defer cg.hwmMu.RUnlock()
+
+	result := make(map[string]map[int32]int64, len(cg.highWaterMarks))
+	for topic, partitions := range cg.highWaterMarks {
+		result[topic] = make(map[int32]int64, len(partitions))
+		for partition, hwm := range partitions {
+			result[topic][partition] = hwm
+		}
+	}
+
+	return result
+}
+
+// Lag returns, for every partition this instance currently has claimed and
+// has delivered at least one message from, the gap between its high-water mark and the last offset this instance has
+// consumed: HighWaterMarks() - lastConsumedOffset.
+func (cg *ConsumerGroup) Lag() map[string]map[int32]int64 {
+	cg.hwmMu.RLock()
+	defer cg.hwmMu.RUnlock()
+	cg.lastConsumedMu.RLock()
+	defer cg.lastConsumedMu.RUnlock()
+
+	result := make(map[string]map[int32]int64, len(cg.highWaterMarks))
+	for topic, partitions := range cg.highWaterMarks {
+		for partition, hwm := range partitions {
+			consumed, ok := cg.lastConsumedOffsets[topic][partition]
+			if !ok {
+				continue
+			}
+			if result[topic] == nil {
+				result[topic] = make(map[int32]int64, len(partitions))
+			}
+			result[topic][partition] = hwm - consumed
+		}
+	}
+
+	return result
+}
+
+func (cg *ConsumerGroup) setHighWaterMark(topic string, partition int32, hwm int64) {
+	cg.hwmMu.Lock()
+	defer cg.hwmMu.Unlock()
+
+	if cg.highWaterMarks[topic] == nil {
+		cg.highWaterMarks[topic] = make(map[int32]int64)
+	}
+	cg.highWaterMarks[topic][partition] = hwm
+}
+
+func (cg *ConsumerGroup) setLastConsumedOffset(topic string, partition int32, offset int64) {
+	cg.lastConsumedMu.Lock()
+	defer cg.lastConsumedMu.Unlock()
+
+	if cg.lastConsumedOffsets[topic] == nil {
+		cg.lastConsumedOffsets[topic] = make(map[int32]int64)
+	}
+	cg.lastConsumedOffsets[topic][partition] = offset
+}
+
+// clearPartitionMetrics drops tracked metrics for a partition this instance
+// no longer owns, so HighWaterMarks/Lag don't report stale data after a
+// rebalance moves the partition elsewhere.
+func (cg *ConsumerGroup) clearPartitionMetrics(topic string, partition int32) {
+	cg.hwmMu.Lock()
+	delete(cg.highWaterMarks[topic], partition)
+	cg.hwmMu.Unlock()
+
+	cg.lastConsumedMu.Lock()
+	delete(cg.lastConsumedOffsets[topic], partition)
+	cg.lastConsumedMu.Unlock()
+}
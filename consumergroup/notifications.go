@@ -0,0 +1,163 @@
+package consumergroup
+
+import "sync"
+
+// NotificationType identifies what triggered a Notification.
+type NotificationType int
+
+// Deviation from the original spec: an earlier draft of this API had a
+// separate PartitionsClaimed type alongside PartitionsReleased. It was
+// dropped deliberately, not silently — a claim and its owning rebalance
+// always happen in the same dividePartitionsBetweenConsumers pass, so a
+// standalone PartitionsClaimed notification would always carry the same
+// Current/Claimed data as the Rebalance notification already emitted for
+// that pass. Newly claimed partitions are reported via Rebalance.Claimed
+// instead; PartitionsReleased stays separate because a release happens on
+// its own, off the rebalance cycle, when a partition consumer loop exits.
+const (
+	// Rebalance is emitted once dividePartitionsBetweenConsumers has
+	// assigned a (possibly unchanged) partition set to this instance.
+	// Newly claimed partitions ride along in its Claimed field; there is no
+	// separate per-partition claimed notification.
+	Rebalance NotificationType = iota
+
+	// PartitionsReleased is emitted when a partition consumer loop exits,
+	// before FinalizePartition waits for and commits the partition's final
+	// offset and before the Zookeeper claim is released.
+	PartitionsReleased
+
+	// InstanceReregistered is emitted after this instance re-registers
+	// itself in Zookeeper because its ephemeral znode was found missing,
+	// e.g. following a session expiry. This is alarm-worthy: it means the
+	// instance dropped out of the group and had to rejoin.
+	InstanceReregistered
+
+	// SubscriptionChanged is emitted by JoinConsumerGroupRegex when it
+	// re-registers this instance in Zookeeper because the set of topics
+	// matching its pattern changed. Unlike InstanceReregistered, this is
+	// routine: it fires on every ordinary topic addition/removal under the
+	// pattern, not just session expiry.
+	SubscriptionChanged
+)
+
+func (t NotificationType) String() string {
+	switch t {
+	case Rebalance:
+		return "Rebalance"
+	case PartitionsReleased:
+		return "PartitionsReleased"
+	case InstanceReregistered:
+		return "InstanceReregistered"
+	case SubscriptionChanged:
+		return "SubscriptionChanged"
+	default:
+		return "Unknown"
+	}
+}
+
+// Notification describes a rebalance-related event: a new partition
+// assignment for a topic, a partition consumer exiting, or this instance
+// re-registering itself in Zookeeper. Claimed, Released and Current are
+// keyed by topic.
+type Notification struct {
+	Type NotificationType
+
+	Claimed  map[string][]int32
+	Released map[string][]int32
+	Current  map[string][]int32
+}
+
+// Notifications returns a channel of rebalance events: one per topic per
+// rebalance cycle once dividePartitionsBetweenConsumers runs, and one more whenever a
+// partition consumer loop exits. Useful for dashboards on rebalance storms,
+// or to flush per-partition state on revocation before FinalizePartition
+// commits the final offset.
+func (cg *ConsumerGroup) Notifications() <-chan *Notification {
+	cg.notificationsOnce.Do(func() {
+		cg.notifications = make(chan *Notification, cg.config.ChannelBufferSize)
+	})
+
+	return cg.notifications
+}
+
+// notify delivers n without blocking the rebalance that produced it. If
+// nobody has called Notifications(), or the channel is full, the event is
+// simply dropped.
+func (cg *ConsumerGroup) notify(n *Notification) {
+	if cg.notifications == nil {
+		return
+	}
+
+	select {
+	case cg.notifications <- n:
+	default:
+	}
+}
+
+// claimsTracker keeps the set of partitions this instance currently owns
+// per topic, so rebalance notifications can report what changed.
+type claimsTracker struct {
+	mu      sync.Mutex
+	current map[string][]int32
+}
+
+func (c *claimsTracker) snapshot() map[string][]int32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make(map[string][]int32, len(c.current))
+	for topic, partitions := range c.current {
+		result[topic] = append([]int32(nil), partitions...)
+	}
+	return result
+}
+
+// update replaces the claimed partitions for topic and returns what was
+// newly claimed and released relative to the previous set.
+func (c *claimsTracker) update(topic string, partitions []int32) (claimed, released []int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current == nil {
+		c.current = make(map[string][]int32)
+	}
+
+	previous := make(map[int32]bool, len(c.current[topic]))
+	for _, p := range c.current[topic] {
+		previous[p] = true
+	}
+
+	next := make(map[int32]bool, len(partitions))
+	for _, p := range partitions {
+		next[p] = true
+		if !previous[p] {
+			claimed = append(claimed, p)
+		}
+	}
+	for _, p := range c.current[topic] {
+		if !next[p] {
+			released = append(released, p)
+		}
+	}
+
+	c.current[topic] = append([]int32(nil), partitions...)
+
+	return claimed, released
+}
+
+// release drops a single partition from the current claim set for topic, so
+// a PartitionsReleased notification's Current reflects the release right
+// away instead of only at the next update(), which doesn't run again until
+// the following rebalance picks a new partition set.
+func (c *claimsTracker) release(topic string, partition int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	partitions := c.current[topic]
+	for i, p := range partitions {
+		if p == partition {
+			c.current[topic] = append(partitions[:i], partitions[i+1:]...)
+			break
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package consumergroup
+
+import (
+	"errors"
+	"time"
+)
+
+// OffsetManager tracks, and periodically commits, the offsets of messages
+// consumed from each partition this instance has claimed.
+// zookeeperOffsetManager, kafkaOffsetManager and dualOffsetManager each
+// implement it against a different backing store.
+type OffsetManager interface {
+	// InitializePartition fetches the last committed offset for
+	// topic/partition, returning -1 if none is found.
+	InitializePartition(topic string, partition int32) (int64, error)
+
+	// FinalizePartition commits the final offset for a partition this
+	// instance is releasing, waiting up to timeout for lastOffset to be
+	// marked processed first.
+	FinalizePartition(topic string, partition int32, lastOffset int64, timeout time.Duration) error
+
+	// MarkAsProcessed records offset as safe to commit for topic/partition.
+	MarkAsProcessed(topic string, partition int32, offset int64) error
+
+	// MarkAsConsumed records offset as the most recently delivered message
+	// for topic/partition, independent of whether it has been processed.
+	MarkAsConsumed(topic string, partition int32, offset int64) error
+
+	Close() error
+}
+
+// OffsetManagerConfig configures how often an OffsetManager flushes queued
+// offsets to its backing store.
+type OffsetManagerConfig struct {
+	// CommitInterval is how often queued offsets are committed.
+	CommitInterval time.Duration
+}
+
+// NewOffsetManagerConfig returns an OffsetManagerConfig with sane defaults.
+func NewOffsetManagerConfig() *OffsetManagerConfig {
+	return &OffsetManagerConfig{
+		CommitInterval: 10 * time.Second,
+	}
+}
+
+// Errors returned by OffsetManager implementations and partitionOffsetTracker.
+var (
+	// NoOffsetToCommit is returned by commitOffset when there is nothing
+	// newer than the last committed offset to commit.
+	NoOffsetToCommit = errors.New("kafka-cg: no offset to commit")
+
+	// TopicPartitionNotFound is returned by MarkAsProcessed/MarkAsConsumed
+	// for a topic/partition this instance hasn't initialized.
+	TopicPartitionNotFound = errors.New("kafka-cg: topic/partition not found")
+
+	// UncleanClose is returned by Close() if partitions were still being
+	// tracked, meaning FinalizePartition never ran for them.
+	UncleanClose = errors.New("kafka-cg: closed with partitions still open")
+
+	// OffsetTooLarge is returned by markAsProcessed when asked to mark an
+	// offset more than one past the last consumed message.
+	OffsetTooLarge = errors.New("kafka-cg: offset is ahead of the last consumed message")
+
+	// OffsetBackwardsError is returned by markAsProcessed when asked to
+	// mark an offset not higher than the highest one already marked
+	// processed.
+	OffsetBackwardsError = errors.New("kafka-cg: offset moves backwards from what was already marked processed")
+)
@@ -0,0 +1,76 @@
+package consumergroup
+
+import "time"
+
+// dualOffsetManager commits/fetches offsets through both the Zookeeper and
+// Kafka backed offset managers at once, so that a consumer group can be cut
+// over from ZK-stored offsets to Kafka-stored offsets without losing its
+// position: Zookeeper stays authoritative for InitializePartition while both
+// stores are kept in sync on every commit.
+type dualOffsetManager struct {
+	zookeeper OffsetManager
+	kafka     OffsetManager
+}
+
+// NewDualOffsetManager returns an offset manager that commits offsets to
+// both Zookeeper and Kafka, for migrating consumer groups between the two
+// storage backends. Returns an error if the Kafka-backed manager can't be
+// set up.
+func NewDualOffsetManager(cg *ConsumerGroup, config *OffsetManagerConfig) (OffsetManager, error) {
+	kafka, err := NewKafkaOffsetManager(cg, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dualOffsetManager{
+		zookeeper: NewZookeeperOffsetManager(cg, config),
+		kafka:     kafka,
+	}, nil
+}
+
+func (dom *dualOffsetManager) InitializePartition(topic string, partition int32) (int64, error) {
+	nextOffset, err := dom.zookeeper.InitializePartition(topic, partition)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := dom.kafka.InitializePartition(topic, partition); err != nil {
+		return 0, err
+	}
+
+	return nextOffset, nil
+}
+
+func (dom *dualOffsetManager) FinalizePartition(topic string, partition int32, lastOffset int64, timeout time.Duration) error {
+	zkErr := dom.zookeeper.FinalizePartition(topic, partition, lastOffset, timeout)
+	kafkaErr := dom.kafka.FinalizePartition(topic, partition, lastOffset, timeout)
+
+	if zkErr != nil {
+		return zkErr
+	}
+	return kafkaErr
+}
+
+func (dom *dualOffsetManager) MarkAsConsumed(topic string, partition int32, offset int64) error {
+	if err := dom.zookeeper.MarkAsConsumed(topic, partition, offset); err != nil {
+		return err
+	}
+	return dom.kafka.MarkAsConsumed(topic, partition, offset)
+}
+
+func (dom *dualOffsetManager) MarkAsProcessed(topic string, partition int32, offset int64) error {
+	if err := dom.zookeeper.MarkAsProcessed(topic, partition, offset); err != nil {
+		return err
+	}
+	return dom.kafka.MarkAsProcessed(topic, partition, offset)
+}
+
+func (dom *dualOffsetManager) Close() error {
+	zkErr := dom.zookeeper.Close()
+	kafkaErr := dom.kafka.Close()
+
+	if zkErr != nil {
+		return zkErr
+	}
+	return kafkaErr
+}
@@ -0,0 +1,240 @@
+package consumergroup
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Storage backends for Config.Offsets.Storage.
+const (
+	OffsetStorageZookeeper = "zookeeper"
+	OffsetStorageKafka     = "kafka"
+	OffsetStorageDual      = "dual"
+)
+
+// kafkaOffsetManager stores consumer offsets in Kafka itself, via sarama's
+// OffsetManager (the OffsetCommit/OffsetFetch APIs against the
+// __consumer_offsets topic), instead of Zookeeper.
+type kafkaOffsetManager struct {
+	config  *OffsetManagerConfig
+	cg      *ConsumerGroup
+	l       sync.RWMutex
+	offsets offsetsMap
+
+	om       sarama.OffsetManager
+	managers map[string]map[int32]sarama.PartitionOffsetManager
+
+	closing, closed chan struct{}
+}
+
+// NewKafkaOffsetManager returns an offset manager that commits/fetches
+// offsets via Kafka's __consumer_offsets topic, using sarama's OffsetManager.
+// Returns an error if sarama can't create an offset manager from cg.client
+// (e.g. the client is already closed); the __consumer_offsets coordinator
+// is not contacted until a partition is initialized.
+func NewKafkaOffsetManager(cg *ConsumerGroup, config *OffsetManagerConfig) (OffsetManager, error) {
+	if config == nil {
+		config = NewOffsetManagerConfig()
+	}
+
+	om, err := sarama.NewOffsetManagerFromClient(cg.group.Name, cg.client)
+	if err != nil {
+		return nil, fmt.Errorf("kafka-cg: create kafka offset manager: %v", err)
+	}
+
+	kom := &kafkaOffsetManager{
+		config:   config,
+		cg:       cg,
+		offsets:  make(offsetsMap),
+		managers: make(map[string]map[int32]sarama.PartitionOffsetManager),
+		om:       om,
+		closing:  make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+
+	go kom.offsetCommitter()
+
+	return kom, nil
+}
+
+func (kom *kafkaOffsetManager) InitializePartition(topic string, partition int32) (int64, error) {
+	kom.l.Lock()
+	defer kom.l.Unlock()
+
+	if kom.offsets[topic] == nil {
+		kom.offsets[topic] = make(topicOffsets)
+	}
+
+	pom, err := kom.om.ManagePartition(topic, partition)
+	if err != nil {
+		return 0, err
+	}
+
+	if kom.managers[topic] == nil {
+		kom.managers[topic] = make(map[int32]sarama.PartitionOffsetManager)
+	}
+	kom.managers[topic][partition] = pom
+
+	// if not found in Kafka, sarama returns Consumer.Offsets.Initial, which
+	// is -1 (OffsetNewest) under sarama's default config
+	nextOffset, _ := pom.NextOffset()
+
+	kom.offsets[topic][partition] = &partitionOffsetTracker{
+		highestMarkedAsProcessedOffset: nextOffset - 1,
+		lastCommittedOffset:            nextOffset - 1,
+		lastConsumedOffset:             nextOffset - 1,
+		done:                           make(chan struct{}),
+	}
+
+	return nextOffset, nil
+}
+
+func (kom *kafkaOffsetManager) FinalizePartition(topic string, partition int32, lastOffset int64, timeout time.Duration) error {
+	kom.l.Lock()
+	defer kom.l.Unlock()
+	tracker := kom.offsets[topic][partition]
+
+	if lastOffset >= 0 {
+		if lastOffset-tracker.highestMarkedAsProcessedOffset > 0 {
+			if !tracker.waitForOffset(lastOffset, timeout) {
+				kom.cg.logWarn("timeout waiting for offset", "topic", topic, "partition", partition,
+					"timeout_seconds", timeout/time.Second, "offset", lastOffset,
+					"lastCommittedOffset", tracker.lastCommittedOffset)
+			}
+		}
+
+		if err := kom.commitOffset(topic, partition, tracker); err != nil && err != NoOffsetToCommit {
+			return fmt.Errorf("FAILED to commit offset %d to Kafka. Last committed offset: %d %v", tracker.highestMarkedAsProcessedOffset, tracker.lastCommittedOffset, err)
+		}
+	}
+
+	if pom, ok := kom.managers[topic][partition]; ok {
+		_ = pom.Close()
+		delete(kom.managers[topic], partition)
+	}
+	delete(kom.offsets[topic], partition)
+
+	return nil
+}
+
+func (kom *kafkaOffsetManager) MarkAsProcessed(topic string, partition int32, offset int64) error {
+	kom.l.RLock()
+	defer kom.l.RUnlock()
+	if p, ok := kom.offsets[topic][partition]; ok {
+		return p.markAsProcessed(offset)
+	} else {
+		return TopicPartitionNotFound
+	}
+}
+
+func (kom *kafkaOffsetManager) MarkAsConsumed(topic string, partition int32, offset int64) error {
+	kom.l.RLock()
+	defer kom.l.RUnlock()
+	if p, ok := kom.offsets[topic][partition]; ok {
+		p.lastConsumedOffset = offset
+		return nil
+	} else {
+		return TopicPartitionNotFound
+	}
+}
+
+func (kom *kafkaOffsetManager) Close() error {
+	close(kom.closing)
+	<-kom.closed
+
+	kom.l.Lock()
+	defer kom.l.Unlock()
+
+	var closeError error
+	for _, partitionOffsets := range kom.offsets {
+		if len(partitionOffsets) > 0 {
+			closeError = UncleanClose
+		}
+	}
+
+	if kom.om != nil {
+		if err := kom.om.Close(); err != nil {
+			closeError = err
+		}
+	}
+
+	return closeError
+}
+
+func (kom *kafkaOffsetManager) offsetCommitter() {
+	commitTicker := time.NewTicker(kom.config.CommitInterval)
+	defer commitTicker.Stop()
+
+	for {
+		select {
+		case <-kom.closing:
+			close(kom.closed)
+			return
+
+		case <-commitTicker.C:
+			kom.commitOffsets()
+		}
+	}
+}
+
+func (kom *kafkaOffsetManager) commitOffsets() {
+	kom.l.RLock()
+	defer kom.l.RUnlock()
+
+	for topic, partitionOffsets := range kom.offsets {
+		for partition, offsetTracker := range partitionOffsets {
+			kom.commitOffset(topic, partition, offsetTracker)
+		}
+	}
+}
+
+// commitOffset hands the highest processed offset off to sarama's
+// PartitionOffsetManager, which owns the actual OffsetCommit request to
+// Kafka. Semantics mirror zookeeperOffsetManager.commitOffset: offset+1 is
+// committed, and NoOffsetToCommit is returned when there is nothing new.
+//
+// MarkOffset only queues the offset for sarama's own asynchronous commit
+// loop, so before treating it as committed we drain pom.Errors() for any
+// failure sarama has already surfaced from a prior commit. This doesn't make
+// the commit synchronous. On an error read from pom.Errors(),
+// lastCommittedOffset is left unadvanced and the next offsetCommitter tick
+// retries. sarama only sends commit failures to pom.Errors() when the
+// config's Consumer.Return.Errors is set; otherwise it logs them itself and
+// this function does not see them.
+func (kom *kafkaOffsetManager) commitOffset(topic string, partition int32, tracker *partitionOffsetTracker) error {
+	pom, ok := kom.managers[topic][partition]
+
+	err := tracker.commit(func(offset int64) error {
+		if offset < 0 {
+			return nil
+		}
+		if !ok || pom == nil {
+			return fmt.Errorf("kafka-cg: no partition offset manager for %s/%d", topic, partition)
+		}
+
+		select {
+		case err := <-pom.Errors():
+			return err
+		default:
+		}
+
+		pom.MarkOffset(offset+1, "")
+
+		select {
+		case err := <-pom.Errors():
+			return err
+		default:
+			return nil
+		}
+	})
+
+	if err != nil && err != NoOffsetToCommit {
+		kom.cg.logError("commit offset", "topic", topic, "partition", partition,
+			"offset", tracker.highestMarkedAsProcessedOffset, "err", err)
+	}
+
+	return err
+}
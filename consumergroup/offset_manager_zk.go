@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"sync"
 	"time"
-
-	log "github.com/funkygao/log4go"
 )
 
 type (
@@ -85,9 +83,9 @@ func (zom *zookeeperOffsetManager) FinalizePartition(topic string, partition int
 	if lastOffset >= 0 {
 		if lastOffset-tracker.highestMarkedAsProcessedOffset > 0 {
 			if !tracker.waitForOffset(lastOffset, timeout) {
-				log.Debug("[%s/%s] %s/%d TIMEOUT %ds waiting for offset %d. Last committed offset: %d", zom.cg.group.Name, zom.cg.shortID(),
-					topic, partition, timeout/time.Second, lastOffset,
-					tracker.lastCommittedOffset)
+				zom.cg.logWarn("timeout waiting for offset", "topic", topic, "partition", partition,
+					"timeout_seconds", timeout/time.Second, "offset", lastOffset,
+					"lastCommittedOffset", tracker.lastCommittedOffset)
 			}
 		}
 
@@ -176,8 +174,8 @@ func (zom *zookeeperOffsetManager) commitOffset(topic string, partition int32, t
 	})
 
 	if err != nil && err != NoOffsetToCommit {
-		log.Debug("[%s/%s] %s/%d commit offset %d: %s", zom.cg.group.Name, zom.cg.shortID(),
-			topic, partition, tracker.highestMarkedAsProcessedOffset, err)
+		zom.cg.logError("commit offset", "topic", topic, "partition", partition,
+			"offset", tracker.highestMarkedAsProcessedOffset, "err", err)
 	}
 
 	return err
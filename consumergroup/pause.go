@@ -0,0 +1,107 @@
+package consumergroup
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pausePollInterval bounds how long a paused partitionConsumerLoop waits
+// before re-checking its paused flag, so Resume takes effect promptly
+// without the loop busy-spinning.
+const pausePollInterval = 250 * time.Millisecond
+
+// pauseTracker holds a per-partition paused flag, keyed the same way as
+// batcher's batches, so Pause/Resume can flip a partition's state without
+// taking a lock on partitionConsumerLoop's hot path: the loop only
+// atomic.Bool.Load()s the flag it was handed when consumePartition started.
+type pauseTracker struct {
+	mu     sync.Mutex
+	paused map[batchKey]*atomic.Bool
+}
+
+// register creates (or returns the existing) paused flag for topic/partition.
+// Called once by consumePartition when it starts consuming, so Pause/Resume
+// can find the flag for any partition this instance currently owns.
+func (p *pauseTracker) register(topic string, partition int32) *atomic.Bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused == nil {
+		p.paused = make(map[batchKey]*atomic.Bool)
+	}
+
+	key := batchKey{topic: topic, partition: partition}
+	flag, ok := p.paused[key]
+	if !ok {
+		flag = new(atomic.Bool)
+		p.paused[key] = flag
+	}
+	return flag
+}
+
+// unregister drops the paused flag for a partition this instance no longer
+// owns, so it doesn't leak across rebalances.
+func (p *pauseTracker) unregister(topic string, partition int32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.paused, batchKey{topic: topic, partition: partition})
+}
+
+// set flips the paused flag for topic/partition, returning an error if this
+// instance doesn't currently own that partition.
+func (p *pauseTracker) set(topic string, partition int32, paused bool) error {
+	p.mu.Lock()
+	flag, ok := p.paused[batchKey{topic: topic, partition: partition}]
+	p.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("kafka-cg: partition %s/%d is not claimed by this instance", topic, partition)
+	}
+
+	flag.Store(paused)
+	return nil
+}
+
+// setAll flips the paused flag for every partition this instance currently
+// owns.
+func (p *pauseTracker) setAll(paused bool) {
+	p.mu.Lock()
+	flags := make([]*atomic.Bool, 0, len(p.paused))
+	for _, flag := range p.paused {
+		flags = append(flags, flag)
+	}
+	p.mu.Unlock()
+
+	for _, flag := range flags {
+		flag.Store(paused)
+	}
+}
+
+// Pause stops partitionConsumerLoop from forwarding messages for
+// topic/partition to the Messages() channel, without releasing the
+// Zookeeper partition claim or advancing offsets. Returns an error if this
+// instance does not currently own the partition. Useful for back-pressure:
+// slowing a specific partition down without forcing a full rebalance by
+// closing the consumer group.
+func (cg *ConsumerGroup) Pause(topic string, partition int32) error {
+	return cg.pauses.set(topic, partition, true)
+}
+
+// Resume undoes Pause, letting partitionConsumerLoop resume forwarding
+// messages for topic/partition.
+func (cg *ConsumerGroup) Resume(topic string, partition int32) error {
+	return cg.pauses.set(topic, partition, false)
+}
+
+// PauseAll pauses every partition this instance currently owns.
+func (cg *ConsumerGroup) PauseAll() {
+	cg.pauses.setAll(true)
+}
+
+// ResumeAll resumes every partition this instance currently owns.
+func (cg *ConsumerGroup) ResumeAll() {
+	cg.pauses.setAll(false)
+}